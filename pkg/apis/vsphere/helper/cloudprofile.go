@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package helper
+
+import (
+	"github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere"
+)
+
+// MergeCloudProfileConfig merges the given NamespacedCloudProfile providerConfig on top of the
+// parent CloudProfile's providerConfig. The parent is always the base: regions and machine images of
+// the namespaced profile are appended only if the parent does not already declare an entry of the same
+// name, so no entry the parent defines is removed or shadowed. All other fields of the namespaced
+// providerConfig, including any failure-domain settings, are not merged.
+func MergeCloudProfileConfig(parent, namespaced *vsphere.CloudProfileConfig) (*vsphere.CloudProfileConfig, error) {
+	if namespaced == nil {
+		return parent, nil
+	}
+
+	merged := parent.DeepCopy()
+
+	for _, region := range namespaced.Regions {
+		if idx := regionIndex(merged.Regions, region.Name); idx >= 0 {
+			continue
+		}
+		merged.Regions = append(merged.Regions, region)
+	}
+
+	for _, image := range namespaced.MachineImages {
+		if idx := machineImageIndex(merged.MachineImages, image.Name); idx >= 0 {
+			continue
+		}
+		merged.MachineImages = append(merged.MachineImages, image)
+	}
+
+	return merged, nil
+}
+
+func regionIndex(regions []vsphere.RegionSpec, name string) int {
+	for i, region := range regions {
+		if region.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func machineImageIndex(images []vsphere.MachineImages, name string) int {
+	for i, image := range images {
+		if image.Name == name {
+			return i
+		}
+	}
+	return -1
+}
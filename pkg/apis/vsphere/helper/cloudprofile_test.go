@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package helper_test
+
+import (
+	"testing"
+
+	"github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere"
+	. "github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere/helper"
+)
+
+func TestMergeCloudProfileConfig_NilNamespacedReturnsParent(t *testing.T) {
+	parent := &vsphere.CloudProfileConfig{Regions: []vsphere.RegionSpec{{Name: "region1"}}}
+
+	merged, err := MergeCloudProfileConfig(parent, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged != parent {
+		t.Errorf("expected the parent to be returned unchanged when there is no namespaced override")
+	}
+}
+
+func TestMergeCloudProfileConfig_AppendsNewRegion(t *testing.T) {
+	parent := &vsphere.CloudProfileConfig{Regions: []vsphere.RegionSpec{{Name: "region1"}}}
+	namespaced := &vsphere.CloudProfileConfig{Regions: []vsphere.RegionSpec{{Name: "region2"}}}
+
+	merged, err := MergeCloudProfileConfig(parent, namespaced)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(merged.Regions) != 2 {
+		t.Fatalf("expected 2 regions after merge, got %d", len(merged.Regions))
+	}
+}
+
+func TestMergeCloudProfileConfig_CannotShadowParentRegion(t *testing.T) {
+	parent := &vsphere.CloudProfileConfig{Regions: []vsphere.RegionSpec{{Name: "region1", Datacenter: "dc-parent"}}}
+	namespaced := &vsphere.CloudProfileConfig{Regions: []vsphere.RegionSpec{{Name: "region1", Datacenter: "dc-namespaced"}}}
+
+	merged, err := MergeCloudProfileConfig(parent, namespaced)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(merged.Regions) != 1 {
+		t.Fatalf("expected the namespaced region1 entry to be dropped, got %d regions", len(merged.Regions))
+	}
+	if merged.Regions[0].Datacenter != "dc-parent" {
+		t.Errorf("expected the parent's region1 definition to win, got datacenter %q", merged.Regions[0].Datacenter)
+	}
+}
+
+func TestMergeCloudProfileConfig_DoesNotMutateParent(t *testing.T) {
+	parent := &vsphere.CloudProfileConfig{Regions: []vsphere.RegionSpec{{Name: "region1"}}}
+	namespaced := &vsphere.CloudProfileConfig{Regions: []vsphere.RegionSpec{{Name: "region2"}}}
+
+	if _, err := MergeCloudProfileConfig(parent, namespaced); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(parent.Regions) != 1 {
+		t.Errorf("expected the parent object passed in to be left untouched, got %d regions", len(parent.Regions))
+	}
+}
@@ -126,3 +126,14 @@ func DecodeCloudProfileConfig(config *gardencorev1beta1.ProviderConfig, fldPath
 
 	return cloudProfileConfig, nil
 }
+
+// DecodeCloudProfileConfigFromCore decodes the providerConfig of a core.NamespacedCloudProfile, which
+// (unlike the external CloudProfile) carries its providerConfig as a *core.ProviderConfig.
+func DecodeCloudProfileConfigFromCore(config *core.ProviderConfig, fldPath *field.Path) (*vsphere.CloudProfileConfig, error) {
+	cloudProfileConfig := &vsphere.CloudProfileConfig{}
+	if err := util.Decode(decoder, config.Raw, cloudProfileConfig); err != nil {
+		return nil, field.Invalid(fldPath, string(config.Raw), "cannot be decoded")
+	}
+
+	return cloudProfileConfig, nil
+}
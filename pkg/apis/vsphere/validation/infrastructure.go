@@ -18,6 +18,8 @@
 package validation
 
 import (
+	"net"
+
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	api "github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere"
@@ -26,17 +28,119 @@ import (
 // ValidateInfrastructureConfig validates a InfrastructureConfig object.
 func ValidateInfrastructureConfig(infra *api.InfrastructureConfig, nodesCIDR *string, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
+
+	if nodesCIDR == nil || *nodesCIDR == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Root().Child("spec", "networking", "nodes"), "a nodes CIDR must be given for vSphere shoots"))
+	} else if _, _, err := net.ParseCIDR(*nodesCIDR); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Root().Child("spec", "networking", "nodes"), *nodesCIDR, "invalid CIDR: "+err.Error()))
+	}
+
+	networksPath := fldPath.Child("networks")
+	dhcp := infra.Networks.DHCP
+	if dhcp != nil && dhcp.Enabled {
+		dhcpPath := networksPath.Child("dhcp")
+		if dhcp.StartAddress == nil || *dhcp.StartAddress == "" {
+			allErrs = append(allErrs, field.Required(dhcpPath.Child("startAddress"), "must be set when dhcp is enabled"))
+		} else if net.ParseIP(*dhcp.StartAddress) == nil {
+			allErrs = append(allErrs, field.Invalid(dhcpPath.Child("startAddress"), *dhcp.StartAddress, "must be a valid IP address"))
+		}
+
+		if dhcp.EndAddress == nil || *dhcp.EndAddress == "" {
+			allErrs = append(allErrs, field.Required(dhcpPath.Child("endAddress"), "must be set when dhcp is enabled"))
+		} else if net.ParseIP(*dhcp.EndAddress) == nil {
+			allErrs = append(allErrs, field.Invalid(dhcpPath.Child("endAddress"), *dhcp.EndAddress, "must be a valid IP address"))
+		}
+
+		if dhcp.StartAddress != nil && dhcp.EndAddress != nil {
+			start, end := net.ParseIP(*dhcp.StartAddress), net.ParseIP(*dhcp.EndAddress)
+			if start != nil && end != nil && bytesCompare(start, end) > 0 {
+				allErrs = append(allErrs, field.Invalid(dhcpPath.Child("endAddress"), *dhcp.EndAddress, "must not be before startAddress"))
+			}
+		}
+	}
+
 	return allErrs
 }
 
 // ValidateInfrastructureConfigUpdate validates a InfrastructureConfig object.
 func ValidateInfrastructureConfigUpdate(oldConfig, newConfig *api.InfrastructureConfig, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
+
+	networksPath := fldPath.Child("networks")
+	if !stringPtrEqual(oldConfig.Networks.NetworkName, newConfig.Networks.NetworkName) {
+		allErrs = append(allErrs, field.Invalid(networksPath.Child("networkName"), newConfig.Networks.NetworkName, "field is immutable, changing it would require re-creating the NSX-T segment"))
+	}
+	if !stringPtrEqual(oldConfig.Networks.Tier0GatewayPath, newConfig.Networks.Tier0GatewayPath) {
+		allErrs = append(allErrs, field.Invalid(networksPath.Child("tier0GatewayPath"), newConfig.Networks.Tier0GatewayPath, "field is immutable, changing it would require re-creating the NSX-T tier-1 gateway"))
+	}
+
 	return allErrs
 }
 
 // ValidateInfrastructureConfigAgainstCloudProfile validates the given InfrastructureConfig against constraints in the given CloudProfile.
 func ValidateInfrastructureConfigAgainstCloudProfile(infra *api.InfrastructureConfig, shootRegion string, cloudProfileConfig *api.CloudProfileConfig, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
+
+	region, ok := findRegion(shootRegion, cloudProfileConfig)
+	if !ok {
+		allErrs = append(allErrs, field.NotFound(fldPath.Root().Child("spec", "region"), shootRegion))
+		return allErrs
+	}
+
+	networksPath := fldPath.Child("networks")
+	if dc := infra.Networks.Datacenter; dc != nil && *dc != "" && *dc != region.Datacenter {
+		allErrs = append(allErrs, field.Invalid(networksPath.Child("datacenter"), *dc, "datacenter is not configured for region "+shootRegion+" in the cloud profile"))
+	}
+	if cc := infra.Networks.ComputeCluster; cc != nil && *cc != "" && !contains(region.ComputeClusters, *cc) {
+		allErrs = append(allErrs, field.Invalid(networksPath.Child("computeCluster"), *cc, "computeCluster is not configured for region "+shootRegion+" in the cloud profile"))
+	}
+	if rp := infra.Networks.ResourcePool; rp != nil && *rp != "" && !contains(region.ResourcePools, *rp) {
+		allErrs = append(allErrs, field.Invalid(networksPath.Child("resourcePool"), *rp, "resourcePool is not configured for region "+shootRegion+" in the cloud profile"))
+	}
+	if ds := infra.Networks.Datastore; ds != nil && *ds != "" && !contains(region.Datastores, *ds) {
+		allErrs = append(allErrs, field.Invalid(networksPath.Child("datastore"), *ds, "datastore is not configured for region "+shootRegion+" in the cloud profile"))
+	}
+	if nw := infra.Networks.NetworkName; nw != nil && *nw != "" && !contains(region.Networks, *nw) {
+		allErrs = append(allErrs, field.Invalid(networksPath.Child("networkName"), *nw, "network is not configured for region "+shootRegion+" in the cloud profile"))
+	}
+
 	return allErrs
 }
+
+func findRegion(name string, cloudProfileConfig *api.CloudProfileConfig) (api.RegionSpec, bool) {
+	for _, region := range cloudProfileConfig.Regions {
+		if region.Name == name {
+			return region, true
+		}
+	}
+	return api.RegionSpec{}, false
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func stringPtrEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func bytesCompare(a, b net.IP) int {
+	a4, b4 := a.To16(), b.To16()
+	for i := range a4 {
+		if a4[i] != b4[i] {
+			if a4[i] < b4[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
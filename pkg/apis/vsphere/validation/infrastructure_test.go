@@ -0,0 +1,166 @@
+/*
+ * Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package validation_test
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	api "github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere"
+	. "github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere/validation"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestValidateInfrastructureConfig_NodesCIDR(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodesCIDR *string
+		wantErr   bool
+	}{
+		{"missing", nil, true},
+		{"empty", strPtr(""), true},
+		{"invalid", strPtr("not-a-cidr"), true},
+		{"valid", strPtr("10.250.0.0/16"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateInfrastructureConfig(&api.InfrastructureConfig{}, tt.nodesCIDR, field.NewPath("infra"))
+			if tt.wantErr && len(errs) == 0 {
+				t.Errorf("expected an error, got none")
+			}
+			if !tt.wantErr && len(errs) != 0 {
+				t.Errorf("expected no error, got %v", errs)
+			}
+		})
+	}
+}
+
+func TestValidateInfrastructureConfig_DHCP(t *testing.T) {
+	tests := []struct {
+		name    string
+		dhcp    *api.DHCP
+		wantErr bool
+	}{
+		{"disabled with no addresses", &api.DHCP{Enabled: false}, false},
+		{"enabled with no addresses", &api.DHCP{Enabled: true}, true},
+		{"enabled with invalid start", &api.DHCP{Enabled: true, StartAddress: strPtr("not-an-ip"), EndAddress: strPtr("10.0.0.10")}, true},
+		{"enabled with end before start", &api.DHCP{Enabled: true, StartAddress: strPtr("10.0.0.10"), EndAddress: strPtr("10.0.0.1")}, true},
+		{"enabled with start equal to end", &api.DHCP{Enabled: true, StartAddress: strPtr("10.0.0.10"), EndAddress: strPtr("10.0.0.10")}, false},
+		{"enabled with valid range", &api.DHCP{Enabled: true, StartAddress: strPtr("10.0.0.10"), EndAddress: strPtr("10.0.0.20")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			infra := &api.InfrastructureConfig{Networks: api.Networks{DHCP: tt.dhcp}}
+			errs := ValidateInfrastructureConfig(infra, strPtr("10.250.0.0/16"), field.NewPath("infra"))
+			if tt.wantErr && len(errs) == 0 {
+				t.Errorf("expected an error, got none")
+			}
+			if !tt.wantErr && len(errs) != 0 {
+				t.Errorf("expected no error, got %v", errs)
+			}
+		})
+	}
+}
+
+func TestValidateInfrastructureConfigUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		oldConfig *api.InfrastructureConfig
+		newConfig *api.InfrastructureConfig
+		wantErr   bool
+	}{
+		{
+			name:      "no-op update",
+			oldConfig: &api.InfrastructureConfig{Networks: api.Networks{NetworkName: strPtr("nw1"), Tier0GatewayPath: strPtr("/infra/tier-0s/t0")}},
+			newConfig: &api.InfrastructureConfig{Networks: api.Networks{NetworkName: strPtr("nw1"), Tier0GatewayPath: strPtr("/infra/tier-0s/t0")}},
+			wantErr:   false,
+		},
+		{
+			name:      "networkName changed",
+			oldConfig: &api.InfrastructureConfig{Networks: api.Networks{NetworkName: strPtr("nw1")}},
+			newConfig: &api.InfrastructureConfig{Networks: api.Networks{NetworkName: strPtr("nw2")}},
+			wantErr:   true,
+		},
+		{
+			name:      "tier0GatewayPath changed",
+			oldConfig: &api.InfrastructureConfig{Networks: api.Networks{Tier0GatewayPath: strPtr("/infra/tier-0s/t0")}},
+			newConfig: &api.InfrastructureConfig{Networks: api.Networks{Tier0GatewayPath: strPtr("/infra/tier-0s/t1")}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateInfrastructureConfigUpdate(tt.oldConfig, tt.newConfig, field.NewPath("infra"))
+			if tt.wantErr && len(errs) == 0 {
+				t.Errorf("expected an error, got none")
+			}
+			if !tt.wantErr && len(errs) != 0 {
+				t.Errorf("expected no error, got %v", errs)
+			}
+		})
+	}
+}
+
+func TestValidateInfrastructureConfigAgainstCloudProfile(t *testing.T) {
+	cloudProfileConfig := &api.CloudProfileConfig{
+		Regions: []api.RegionSpec{
+			{
+				Name:            "region1",
+				Datacenter:      "dc1",
+				ComputeClusters: []string{"cc1"},
+				ResourcePools:   []string{"rp1"},
+				Datastores:      []string{"ds1"},
+				Networks:        []string{"nw1"},
+			},
+		},
+	}
+
+	t.Run("unknown region", func(t *testing.T) {
+		errs := ValidateInfrastructureConfigAgainstCloudProfile(&api.InfrastructureConfig{}, "unknown", cloudProfileConfig, field.NewPath("infra"))
+		if len(errs) == 0 {
+			t.Errorf("expected an error for an unknown region, got none")
+		}
+	})
+
+	t.Run("unconfigured datacenter", func(t *testing.T) {
+		infra := &api.InfrastructureConfig{Networks: api.Networks{Datacenter: strPtr("dc2")}}
+		errs := ValidateInfrastructureConfigAgainstCloudProfile(infra, "region1", cloudProfileConfig, field.NewPath("infra"))
+		if len(errs) == 0 {
+			t.Errorf("expected an error for an unconfigured datacenter, got none")
+		}
+	})
+
+	t.Run("matching configuration", func(t *testing.T) {
+		infra := &api.InfrastructureConfig{Networks: api.Networks{
+			Datacenter:     strPtr("dc1"),
+			ComputeCluster: strPtr("cc1"),
+			ResourcePool:   strPtr("rp1"),
+			Datastore:      strPtr("ds1"),
+			NetworkName:    strPtr("nw1"),
+		}}
+		errs := ValidateInfrastructureConfigAgainstCloudProfile(infra, "region1", cloudProfileConfig, field.NewPath("infra"))
+		if len(errs) != 0 {
+			t.Errorf("expected no error, got %v", errs)
+		}
+	})
+}
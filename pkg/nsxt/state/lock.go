@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package state
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// leaseNamePrefix is prepended to the shoot's technical id to derive the name of the lock Lease.
+const leaseNamePrefix = "nsxt-state-lock-"
+
+// leaseDuration is how long a Lock is held before it is considered stale and can be taken over.
+const leaseDuration = 2 * time.Minute
+
+// Lock acquires the Lease guarding this shoot's NSX-T state, so that two parallel reconciles of the same
+// shoot cannot read-modify-write the state ConfigMap concurrently and corrupt it. holderIdentity should
+// uniquely identify the reconcile attempt (e.g. the operation id).
+func (m *Manager) Lock(ctx context.Context, holderIdentity string) error {
+	now := metav1.NewMicroTime(time.Now())
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      leaseNamePrefix + m.leaseSuffix(),
+			Namespace: m.namespace,
+		},
+	}
+
+	if err := m.client.Get(ctx, client.ObjectKeyFromObject(lease), lease); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("could not get NSX-T state lock %s/%s: %v", m.namespace, lease.Name, err)
+		}
+		lease.Spec = coordinationv1.LeaseSpec{
+			HolderIdentity: &holderIdentity,
+			AcquireTime:    &now,
+		}
+		if err := m.client.Create(ctx, lease); err != nil {
+			return fmt.Errorf("could not acquire NSX-T state lock %s/%s: %v", m.namespace, lease.Name, err)
+		}
+		return nil
+	}
+
+	if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != holderIdentity {
+		if lease.Spec.AcquireTime != nil && time.Since(lease.Spec.AcquireTime.Time) < leaseDuration {
+			return fmt.Errorf("NSX-T state %s/%s is locked by %q", m.namespace, m.name, *lease.Spec.HolderIdentity)
+		}
+	}
+
+	lease.Spec.HolderIdentity = &holderIdentity
+	lease.Spec.AcquireTime = &now
+	if err := m.client.Update(ctx, lease); err != nil {
+		return fmt.Errorf("could not acquire NSX-T state lock %s/%s: %v", m.namespace, lease.Name, err)
+	}
+	return nil
+}
+
+// Unlock releases the Lease acquired by Lock, but only if it is still held by holderIdentity. This must
+// be the same identity passed to Lock: without it, a reconcile whose lock was already stolen by another
+// holder after a staleness timeout would delete that new holder's lease via its own deferred Unlock,
+// defeating the mutual exclusion Lock/Unlock exist to provide. It is a no-op if the Lease does not exist
+// or is held by someone else.
+func (m *Manager) Unlock(ctx context.Context, holderIdentity string) error {
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      leaseNamePrefix + m.leaseSuffix(),
+			Namespace: m.namespace,
+		},
+	}
+	if err := m.client.Get(ctx, client.ObjectKeyFromObject(lease), lease); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("could not get NSX-T state lock %s/%s: %v", m.namespace, lease.Name, err)
+	}
+
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holderIdentity {
+		return nil
+	}
+
+	if err := m.client.Delete(ctx, lease); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("could not release NSX-T state lock %s/%s: %v", m.namespace, lease.Name, err)
+	}
+	return nil
+}
+
+func (m *Manager) leaseSuffix() string {
+	return m.name[len(configMapNamePrefix):]
+}
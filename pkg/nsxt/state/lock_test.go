@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package state_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/gardener/gardener-extension-provider-vsphere/pkg/nsxt/state"
+)
+
+var testEncryptionKey = []byte("01234567890123456789012345678901")
+
+func newFakeClient() client.Client {
+	scheme := runtime.NewScheme()
+	_ = coordinationv1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+func TestLockUnlock_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient()
+	m, err := NewManager(c, "shoot-ns", "shoot--foo--bar", testEncryptionKey)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	if err := m.Lock(ctx, "holder-a"); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if err := m.Unlock(ctx, "holder-a"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+
+	// locking again after a clean unlock must succeed immediately.
+	if err := m.Lock(ctx, "holder-b"); err != nil {
+		t.Fatalf("Lock by a second holder after Unlock failed: %v", err)
+	}
+}
+
+func TestLock_RejectsConcurrentHolderWhileFresh(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient()
+	m, err := NewManager(c, "shoot-ns", "shoot--foo--bar", testEncryptionKey)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	if err := m.Lock(ctx, "holder-a"); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	if err := m.Lock(ctx, "holder-b"); err == nil {
+		t.Errorf("expected Lock by a second holder to fail while the first holder's lease is still fresh")
+	}
+}
+
+func TestLock_AllowsTakeoverOfStaleLease(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient()
+	m, err := NewManager(c, "shoot-ns", "shoot--foo--bar", testEncryptionKey)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	if err := m.Lock(ctx, "holder-a"); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	// simulate the lease having gone stale by backdating its acquire time directly.
+	lease := &coordinationv1.Lease{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "shoot-ns", Name: "nsxt-state-lock-shoot--foo--bar"}, lease); err != nil {
+		t.Fatalf("could not read lease: %v", err)
+	}
+	stale := metav1.NewMicroTime(time.Now().Add(-10 * time.Minute))
+	lease.Spec.AcquireTime = &stale
+	if err := c.Update(ctx, lease); err != nil {
+		t.Fatalf("could not backdate lease: %v", err)
+	}
+
+	if err := m.Lock(ctx, "holder-b"); err != nil {
+		t.Errorf("expected holder-b to be able to take over a stale lease, got: %v", err)
+	}
+}
+
+func TestUnlock_DoesNotReleaseLeaseHeldBySomeoneElse(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient()
+	m, err := NewManager(c, "shoot-ns", "shoot--foo--bar", testEncryptionKey)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	if err := m.Lock(ctx, "holder-a"); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	// holder-b does not hold the lock, holder-a does; holder-b's deferred Unlock must not release it.
+	if err := m.Unlock(ctx, "holder-b"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+
+	lease := &coordinationv1.Lease{}
+	err = c.Get(ctx, client.ObjectKey{Namespace: "shoot-ns", Name: "nsxt-state-lock-shoot--foo--bar"}, lease)
+	if apierrors.IsNotFound(err) {
+		t.Fatalf("expected holder-a's lease to still exist, but it was deleted by holder-b's Unlock")
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != "holder-a" {
+		t.Errorf("expected the lease to still be held by holder-a")
+	}
+}
+
+func TestUnlock_NoopWhenLeaseDoesNotExist(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient()
+	m, err := NewManager(c, "shoot-ns", "shoot--foo--bar", testEncryptionKey)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	if err := m.Unlock(ctx, "holder-a"); err != nil {
+		t.Errorf("expected Unlock of a non-existent lease to be a no-op, got: %v", err)
+	}
+}
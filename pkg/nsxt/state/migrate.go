@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package state
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere"
+)
+
+// Migrate returns the current State, importing it from the legacy InfrastructureStatus on first run if no
+// state ConfigMap exists yet. The imported State is written back so that subsequent reconciles read it
+// directly without needing the InfrastructureStatus at all.
+func (m *Manager) Migrate(ctx context.Context, infraStatus *vsphere.InfrastructureStatus) (*State, error) {
+	state, err := m.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		return state, nil
+	}
+
+	imported := fromInfrastructureStatus(infraStatus)
+	if err := m.Write(ctx, imported); err != nil {
+		return nil, fmt.Errorf("could not migrate NSX-T state %s/%s from InfrastructureStatus: %v", m.namespace, m.name, err)
+	}
+	return imported, nil
+}
+
+func fromInfrastructureStatus(infraStatus *vsphere.InfrastructureStatus) *State {
+	if infraStatus == nil {
+		return &State{}
+	}
+	return &State{
+		Tier1GatewayID:  infraStatus.NSXTInfraState.Tier1GatewayID,
+		DHCPServerID:    infraStatus.NSXTInfraState.DHCPServerID,
+		SegmentIDs:      infraStatus.NSXTInfraState.SegmentIDs,
+		IPPoolIDs:       infraStatus.NSXTInfraState.IPPoolIDs,
+		SNATRuleIDs:     infraStatus.NSXTInfraState.SNATRuleIDs,
+		LoadBalancerIDs: infraStatus.NSXTInfraState.LoadBalancerIDs,
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package state persists the graph of NSX-T objects created for a shoot (tier-1 router, DHCP server,
+// segments, IP pools, SNAT rules, load balancers) into a dedicated ConfigMap in the shoot's control-plane
+// namespace, guarded by a Lease. This gives the infrastructure actuator a durable, incrementally-updatable
+// record of what has already been created, so a partially-failed reconcile can resume instead of having to
+// redo (or lose track of) everything, which is what the previous all-or-nothing InfrastructureStatus
+// round-trip forced. The state is encrypted at rest with AES-256-GCM before being written to the
+// ConfigMap, the same way the terraformer encrypts its RawState before persisting it.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// configMapNamePrefix is prepended to the shoot's technical id to derive the name of the state ConfigMap.
+	configMapNamePrefix = "nsxt-state-"
+	// dataKey is the ConfigMap data key the marshalled State is stored under.
+	dataKey = "state"
+)
+
+// State is the durable record of every NSX-T object created for a shoot's infrastructure.
+type State struct {
+	Tier1GatewayID  string   `json:"tier1GatewayID,omitempty"`
+	DHCPServerID    string   `json:"dhcpServerID,omitempty"`
+	SegmentIDs      []string `json:"segmentIDs,omitempty"`
+	IPPoolIDs       []string `json:"ipPoolIDs,omitempty"`
+	SNATRuleIDs     []string `json:"snatRuleIDs,omitempty"`
+	LoadBalancerIDs []string `json:"loadBalancerIDs,omitempty"`
+}
+
+// Manager reads and writes the NSX-T State of a single shoot.
+type Manager struct {
+	client        client.Client
+	namespace     string
+	name          string
+	encryptionKey []byte
+}
+
+// NewManager creates a Manager for the given shoot's control-plane namespace. encryptionKey must be
+// EncryptionKeySize bytes long and is used to encrypt the State before it is written to the ConfigMap,
+// and to decrypt it on Read; callers typically source it from the garden cluster's etcd encryption key
+// or a comparable per-seed secret.
+func NewManager(c client.Client, namespace, shootTechnicalID string, encryptionKey []byte) (*Manager, error) {
+	if len(encryptionKey) != EncryptionKeySize {
+		return nil, fmt.Errorf("NSX-T state encryption key must be %d bytes, got %d", EncryptionKeySize, len(encryptionKey))
+	}
+	return &Manager{
+		client:        c,
+		namespace:     namespace,
+		name:          configMapNamePrefix + shootTechnicalID,
+		encryptionKey: encryptionKey,
+	}, nil
+}
+
+// Read loads the State from its ConfigMap. It returns nil, nil if no state has been written yet.
+func (m *Manager) Read(ctx context.Context) (*State, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := m.client.Get(ctx, client.ObjectKey{Namespace: m.namespace, Name: m.name}, configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read NSX-T state %s/%s: %v", m.namespace, m.name, err)
+	}
+
+	raw, err := decrypt(m.encryptionKey, configMap.Data[dataKey])
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt NSX-T state %s/%s: %v", m.namespace, m.name, err)
+	}
+
+	state := &State{}
+	if err := json.Unmarshal(raw, state); err != nil {
+		return nil, fmt.Errorf("could not unmarshal NSX-T state %s/%s: %v", m.namespace, m.name, err)
+	}
+	return state, nil
+}
+
+// Write persists the given State, creating the ConfigMap if it does not exist yet.
+func (m *Manager) Write(ctx context.Context, state *State) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("could not marshal NSX-T state: %v", err)
+	}
+
+	encrypted, err := encrypt(m.encryptionKey, raw)
+	if err != nil {
+		return fmt.Errorf("could not encrypt NSX-T state: %v", err)
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.name,
+			Namespace: m.namespace,
+		},
+	}
+
+	_, err = controllerutil.CreateOrUpdate(ctx, m.client, configMap, func() error {
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[dataKey] = encrypted
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not write NSX-T state %s/%s: %v", m.namespace, m.name, err)
+	}
+	return nil
+}
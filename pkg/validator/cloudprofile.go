@@ -0,0 +1,317 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
+
+	"github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere"
+	"github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere/helper"
+	vspherevalidation "github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere/validation"
+)
+
+// CloudProfile validates the vsphere providerConfig of CloudProfile and NamespacedCloudProfile resources
+// on CREATE/UPDATE, so that a broken provider config is rejected before it is persisted rather than only
+// being discovered when a shoot reconciles.
+type CloudProfile struct {
+	client client.Client
+}
+
+// Validate validates the given CloudProfile or NamespacedCloudProfile object.
+func (c *CloudProfile) Validate(ctx context.Context, new, old runtime.Object) error {
+	switch cp := new.(type) {
+	case *gardencorev1beta1.CloudProfile:
+		var oldCp *gardencorev1beta1.CloudProfile
+		if old != nil {
+			oldCp, _ = old.(*gardencorev1beta1.CloudProfile)
+		}
+		return c.validateCloudProfile(ctx, cp, oldCp)
+	case *gardencorev1beta1.NamespacedCloudProfile:
+		return c.validateNamespacedCloudProfile(ctx, cp)
+	default:
+		return fmt.Errorf("wrong object type %T", new)
+	}
+}
+
+func (c *CloudProfile) validateCloudProfile(ctx context.Context, cloudProfile, oldCloudProfile *gardencorev1beta1.CloudProfile) error {
+	if cloudProfile.Spec.ProviderConfig == nil {
+		return nil
+	}
+
+	fldPath := field.NewPath("spec", "providerConfig")
+	cloudProfileConfig, err := helper.DecodeCloudProfileConfig(cloudProfile.Spec.ProviderConfig, fldPath)
+	if err != nil {
+		return err
+	}
+
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, vspherevalidation.ValidateCloudProfileConfig(cloudProfileConfig)...)
+	allErrs = append(allErrs, validateMachineImagesDeclared(cloudProfileConfig, cloudProfile.Spec.MachineImages, fldPath)...)
+	allErrs = append(allErrs, validateRegionsComplete(cloudProfileConfig, fldPath)...)
+
+	if oldCloudProfile != nil && oldCloudProfile.Spec.ProviderConfig != nil {
+		oldCloudProfileConfig, err := helper.DecodeCloudProfileConfig(oldCloudProfile.Spec.ProviderConfig, fldPath)
+		if err == nil {
+			inUseErrs, err := c.validateNoRemovalOfInUseEntries(ctx, cloudProfile.Name, oldCloudProfileConfig, cloudProfileConfig, fldPath)
+			if err != nil {
+				return err
+			}
+			allErrs = append(allErrs, inUseErrs...)
+		}
+	}
+
+	if len(allErrs) != 0 {
+		return allErrs.ToAggregate()
+	}
+	return nil
+}
+
+func (c *CloudProfile) validateNamespacedCloudProfile(ctx context.Context, namespacedCloudProfile *gardencorev1beta1.NamespacedCloudProfile) error {
+	if namespacedCloudProfile.Spec.ProviderConfig == nil {
+		return nil
+	}
+
+	fldPath := field.NewPath("spec", "providerConfig")
+	cloudProfileConfig, err := helper.DecodeCloudProfileConfig(namespacedCloudProfile.Spec.ProviderConfig, fldPath)
+	if err != nil {
+		return err
+	}
+
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, vspherevalidation.ValidateCloudProfileConfig(cloudProfileConfig)...)
+	allErrs = append(allErrs, validateRegionsComplete(cloudProfileConfig, fldPath)...)
+
+	parentCloudProfile := &gardencorev1beta1.CloudProfile{}
+	if err := c.client.Get(ctx, kutil.Key(namespacedCloudProfile.Spec.Parent.Name), parentCloudProfile); err != nil {
+		return fmt.Errorf("could not get parent CloudProfile %q: %v", namespacedCloudProfile.Spec.Parent.Name, err)
+	}
+	if parentCloudProfile.Spec.ProviderConfig != nil {
+		parentConfig, err := helper.DecodeCloudProfileConfig(parentCloudProfile.Spec.ProviderConfig, fldPath)
+		if err != nil {
+			return fmt.Errorf("an error occurred while reading the parent cloud profile %q: %v", parentCloudProfile.Name, err)
+		}
+		allErrs = append(allErrs, validateNamespacedCloudProfileConfig(cloudProfileConfig, parentConfig, fldPath)...)
+	}
+
+	if len(allErrs) != 0 {
+		return allErrs.ToAggregate()
+	}
+	return nil
+}
+
+// validateNamespacedCloudProfileConfig ensures a namespaced providerConfig only ever adds regions or
+// machine images on top of the parent, it must never remove or shadow an entry the parent already declares.
+func validateNamespacedCloudProfileConfig(namespaced, parent *vsphere.CloudProfileConfig, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, region := range namespaced.Regions {
+		for _, parentRegion := range parent.Regions {
+			if parentRegion.Name == region.Name {
+				allErrs = append(allErrs, field.Duplicate(fldPath.Child("regions").Index(i).Child("name"), region.Name))
+			}
+		}
+	}
+
+	for i, image := range namespaced.MachineImages {
+		for _, parentImage := range parent.MachineImages {
+			if parentImage.Name == image.Name {
+				allErrs = append(allErrs, field.Duplicate(fldPath.Child("machineImages").Index(i).Child("name"), image.Name))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validateMachineImagesDeclared ensures every machineImage/version listed in the provider config is also
+// declared in spec.machineImages, so the generic gardener scheduling/maintenance logic knows about it.
+func validateMachineImagesDeclared(cloudProfileConfig *vsphere.CloudProfileConfig, machineImages []gardencorev1beta1.MachineImage, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, image := range cloudProfileConfig.MachineImages {
+		imagesPath := fldPath.Child("machineImages").Index(i)
+		declaredImage := findMachineImage(machineImages, image.Name)
+		if declaredImage == nil {
+			allErrs = append(allErrs, field.Invalid(imagesPath.Child("name"), image.Name, "is not declared in spec.machineImages"))
+			continue
+		}
+		for j, version := range image.Versions {
+			if !machineImageVersionDeclared(declaredImage, version.Version) {
+				allErrs = append(allErrs, field.Invalid(imagesPath.Child("versions").Index(j).Child("version"), version.Version, fmt.Sprintf("version is not declared in spec.machineImages[%q]", image.Name)))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func findMachineImage(machineImages []gardencorev1beta1.MachineImage, name string) *gardencorev1beta1.MachineImage {
+	for i, image := range machineImages {
+		if image.Name == name {
+			return &machineImages[i]
+		}
+	}
+	return nil
+}
+
+func machineImageVersionDeclared(image *gardencorev1beta1.MachineImage, version string) bool {
+	for _, v := range image.Versions {
+		if v.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRegionsComplete ensures every region carries the minimum information the actuator needs to
+// place VMs: a datacenter, at least one compute cluster and at least one datastore.
+func validateRegionsComplete(cloudProfileConfig *vsphere.CloudProfileConfig, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, region := range cloudProfileConfig.Regions {
+		regionPath := fldPath.Child("regions").Index(i)
+		if region.Datacenter == "" {
+			allErrs = append(allErrs, field.Required(regionPath.Child("datacenter"), "must not be empty"))
+		}
+		if len(region.ComputeClusters) == 0 {
+			allErrs = append(allErrs, field.Required(regionPath.Child("computeClusters"), "must not be empty"))
+		}
+		if len(region.Datastores) == 0 {
+			allErrs = append(allErrs, field.Required(regionPath.Child("datastores"), "must not be empty"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateNoRemovalOfInUseEntries rejects removing a region or a machine image version that is still
+// referenced by an existing shoot in the garden.
+func (c *CloudProfile) validateNoRemovalOfInUseEntries(ctx context.Context, cloudProfileName string, oldConfig, newConfig *vsphere.CloudProfileConfig, fldPath *field.Path) (field.ErrorList, error) {
+	removedRegions := regionsRemoved(oldConfig, newConfig)
+	removedVersions := machineImageVersionsRemoved(oldConfig, newConfig)
+	if len(removedRegions) == 0 && len(removedVersions) == 0 {
+		return nil, nil
+	}
+
+	shootList := &gardencorev1beta1.ShootList{}
+	if err := c.client.List(ctx, shootList); err != nil {
+		return nil, fmt.Errorf("could not list shoots to check CloudProfile usage: %v", err)
+	}
+
+	allErrs := field.ErrorList{}
+	for i := range shootList.Items {
+		shoot := &shootList.Items[i]
+
+		shootCloudProfileName, err := c.effectiveCloudProfileName(ctx, shoot)
+		if err != nil {
+			return nil, err
+		}
+		if shootCloudProfileName != cloudProfileName {
+			continue
+		}
+
+		if removedRegions[shoot.Spec.Region] {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("regions"), fmt.Sprintf("region %q is still used by shoot %s/%s", shoot.Spec.Region, shoot.Namespace, shoot.Name)))
+		}
+
+		for _, worker := range shoot.Spec.Provider.Workers {
+			if worker.Machine.Image == nil {
+				continue
+			}
+			version := ""
+			if worker.Machine.Image.Version != nil {
+				version = *worker.Machine.Image.Version
+			}
+			if removedVersions[worker.Machine.Image.Name+"@"+version] {
+				allErrs = append(allErrs, field.Forbidden(fldPath.Child("machineImages"), fmt.Sprintf("machine image %s@%s is still used by shoot %s/%s", worker.Machine.Image.Name, version, shoot.Namespace, shoot.Name)))
+			}
+		}
+	}
+
+	return allErrs, nil
+}
+
+// effectiveCloudProfileName returns the name of the CloudProfile effectively used by the given shoot,
+// following spec.cloudProfile (GEP-25) to its parent CloudProfile when it references a
+// NamespacedCloudProfile, and falling back to the legacy spec.cloudProfileName otherwise.
+func (c *CloudProfile) effectiveCloudProfileName(ctx context.Context, shoot *gardencorev1beta1.Shoot) (string, error) {
+	if shoot.Spec.CloudProfile == nil {
+		return shoot.Spec.CloudProfileName, nil
+	}
+	if shoot.Spec.CloudProfile.Kind != "NamespacedCloudProfile" {
+		return shoot.Spec.CloudProfile.Name, nil
+	}
+
+	namespacedCloudProfile := &gardencorev1beta1.NamespacedCloudProfile{}
+	if err := c.client.Get(ctx, kutil.Key(shoot.Namespace, shoot.Spec.CloudProfile.Name), namespacedCloudProfile); err != nil {
+		return "", fmt.Errorf("could not get NamespacedCloudProfile %q: %v", shoot.Spec.CloudProfile.Name, err)
+	}
+	return namespacedCloudProfile.Spec.Parent.Name, nil
+}
+
+func regionsRemoved(oldConfig, newConfig *vsphere.CloudProfileConfig) map[string]bool {
+	removed := map[string]bool{}
+	for _, region := range oldConfig.Regions {
+		if regionIndexIn(newConfig.Regions, region.Name) < 0 {
+			removed[region.Name] = true
+		}
+	}
+	return removed
+}
+
+func regionIndexIn(regions []vsphere.RegionSpec, name string) int {
+	for i, region := range regions {
+		if region.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func machineImageVersionsRemoved(oldConfig, newConfig *vsphere.CloudProfileConfig) map[string]bool {
+	removed := map[string]bool{}
+	for _, oldImage := range oldConfig.MachineImages {
+		var newImage *vsphere.MachineImages
+		for i, image := range newConfig.MachineImages {
+			if image.Name == oldImage.Name {
+				newImage = &newConfig.MachineImages[i]
+				break
+			}
+		}
+		for _, version := range oldImage.Versions {
+			if newImage == nil || !versionIn(newImage.Versions, version.Version) {
+				removed[oldImage.Name+"@"+version.Version] = true
+			}
+		}
+	}
+	return removed
+}
+
+func versionIn(versions []vsphere.MachineImageVersion, version string) bool {
+	for _, v := range versions {
+		if v.Version == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gardencoreinstall "github.com/gardener/gardener/pkg/apis/core/install"
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+
+	"github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere"
+)
+
+func newCloudProfileFakeClient() client.Client {
+	scheme := runtime.NewScheme()
+	gardencoreinstall.Install(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+func shootUsingRegionAndImage(name, namespace, region, imageName, imageVersion string) *gardencorev1beta1.Shoot {
+	version := imageVersion
+	return &gardencorev1beta1.Shoot{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: gardencorev1beta1.ShootSpec{
+			CloudProfileName: "vsphere",
+			Region:           region,
+			Provider: gardencorev1beta1.Provider{
+				Workers: []gardencorev1beta1.Worker{
+					{
+						Machine: gardencorev1beta1.Machine{
+							Image: &gardencorev1beta1.ShootMachineImage{Name: imageName, Version: &version},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestValidateNoRemovalOfInUseEntries_RegionStillUsedIsForbidden(t *testing.T) {
+	ctx := context.Background()
+	c := newCloudProfileFakeClient()
+	cp := &CloudProfile{client: c}
+
+	shoot := shootUsingRegionAndImage("shoot1", "garden-foo", "region1", "ubuntu", "1.0.0")
+	if err := c.Create(ctx, shoot); err != nil {
+		t.Fatalf("could not create fixture shoot: %v", err)
+	}
+
+	oldConfig := &vsphere.CloudProfileConfig{Regions: []vsphere.RegionSpec{{Name: "region1"}}}
+	newConfig := &vsphere.CloudProfileConfig{}
+
+	errList, err := cp.validateNoRemovalOfInUseEntries(ctx, "vsphere", oldConfig, newConfig, providerConfigPath)
+	if err != nil {
+		t.Fatalf("validateNoRemovalOfInUseEntries failed: %v", err)
+	}
+	if len(errList) != 1 {
+		t.Fatalf("expected removing region1 while shoot1 still uses it to be forbidden, got %d errors", len(errList))
+	}
+}
+
+func TestValidateNoRemovalOfInUseEntries_RegionUnusedIsAllowed(t *testing.T) {
+	ctx := context.Background()
+	c := newCloudProfileFakeClient()
+	cp := &CloudProfile{client: c}
+
+	shoot := shootUsingRegionAndImage("shoot1", "garden-foo", "region2", "ubuntu", "1.0.0")
+	if err := c.Create(ctx, shoot); err != nil {
+		t.Fatalf("could not create fixture shoot: %v", err)
+	}
+
+	oldConfig := &vsphere.CloudProfileConfig{Regions: []vsphere.RegionSpec{{Name: "region1"}}}
+	newConfig := &vsphere.CloudProfileConfig{}
+
+	errList, err := cp.validateNoRemovalOfInUseEntries(ctx, "vsphere", oldConfig, newConfig, providerConfigPath)
+	if err != nil {
+		t.Fatalf("validateNoRemovalOfInUseEntries failed: %v", err)
+	}
+	if len(errList) != 0 {
+		t.Errorf("expected removing an unused region to be allowed, got %d errors", len(errList))
+	}
+}
+
+func TestValidateNoRemovalOfInUseEntries_MachineImageVersionStillUsedIsForbidden(t *testing.T) {
+	ctx := context.Background()
+	c := newCloudProfileFakeClient()
+	cp := &CloudProfile{client: c}
+
+	shoot := shootUsingRegionAndImage("shoot1", "garden-foo", "region1", "ubuntu", "1.0.0")
+	if err := c.Create(ctx, shoot); err != nil {
+		t.Fatalf("could not create fixture shoot: %v", err)
+	}
+
+	oldConfig := &vsphere.CloudProfileConfig{
+		MachineImages: []vsphere.MachineImages{
+			{Name: "ubuntu", Versions: []vsphere.MachineImageVersion{{Version: "1.0.0"}}},
+		},
+	}
+	newConfig := &vsphere.CloudProfileConfig{
+		MachineImages: []vsphere.MachineImages{
+			{Name: "ubuntu"},
+		},
+	}
+
+	errList, err := cp.validateNoRemovalOfInUseEntries(ctx, "vsphere", oldConfig, newConfig, providerConfigPath)
+	if err != nil {
+		t.Fatalf("validateNoRemovalOfInUseEntries failed: %v", err)
+	}
+	if len(errList) != 1 {
+		t.Fatalf("expected removing ubuntu@1.0.0 while shoot1 still uses it to be forbidden, got %d errors", len(errList))
+	}
+}
+
+func TestValidateNoRemovalOfInUseEntries_FollowsGEP25CloudProfileReference(t *testing.T) {
+	ctx := context.Background()
+	c := newCloudProfileFakeClient()
+	cp := &CloudProfile{client: c}
+
+	shoot := shootUsingRegionAndImage("shoot1", "garden-foo", "region1", "ubuntu", "1.0.0")
+	shoot.Spec.CloudProfileName = ""
+	shoot.Spec.CloudProfile = &gardencorev1beta1.CloudProfileReference{Kind: "CloudProfile", Name: "vsphere"}
+	if err := c.Create(ctx, shoot); err != nil {
+		t.Fatalf("could not create fixture shoot: %v", err)
+	}
+
+	oldConfig := &vsphere.CloudProfileConfig{Regions: []vsphere.RegionSpec{{Name: "region1"}}}
+	newConfig := &vsphere.CloudProfileConfig{}
+
+	errList, err := cp.validateNoRemovalOfInUseEntries(ctx, "vsphere", oldConfig, newConfig, providerConfigPath)
+	if err != nil {
+		t.Fatalf("validateNoRemovalOfInUseEntries failed: %v", err)
+	}
+	if len(errList) != 1 {
+		t.Fatalf("expected a shoot referencing the CloudProfile via spec.cloudProfile (GEP-25) to still be accounted for, got %d errors", len(errList))
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/gardener/gardener/pkg/apis/core"
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
+
+	"github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere"
+	"github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere/helper"
+)
+
+// Context is a per-admission-request cache for a shoot's CloudProfile. validateInfraAgainstCloudProfile
+// and validateCpAgainstCloudProfile both need the same CloudProfile (and, per GEP-25, the same resolved
+// NamespacedCloudProfile) and its decoded vsphere.CloudProfileConfig; without this cache each of them
+// issued its own client.Get and decode, doubling the API-server round trips a single admission request
+// causes. Context loads both lazily on first use and memoizes the result for the lifetime of the request.
+type Context struct {
+	client client.Client
+	shoot  *core.Shoot
+
+	loaded                 bool
+	cloudProfile           *gardencorev1beta1.CloudProfile
+	namespacedCloudProfile *core.NamespacedCloudProfile
+	cloudProfileConfig     *vsphere.CloudProfileConfig
+}
+
+// NewContext creates a Context for the given shoot.
+func NewContext(c client.Client, shoot *core.Shoot) *Context {
+	return &Context{client: c, shoot: shoot}
+}
+
+// GetCloudProfile returns the CloudProfile effectively used by the shoot. For a NamespacedCloudProfile
+// reference this is the parent CloudProfile, since that is what callers need for validations that are not
+// aware of the namespaced override.
+func (c *Context) GetCloudProfile(ctx context.Context) (*gardencorev1beta1.CloudProfile, error) {
+	if err := c.load(ctx); err != nil {
+		return nil, err
+	}
+	return c.cloudProfile, nil
+}
+
+// GetCloudProfileConfig returns the vsphere CloudProfileConfig effectively used by the shoot, merging in
+// a NamespacedCloudProfile override on top of the parent CloudProfile's providerConfig if GEP-25's
+// spec.cloudProfile references one.
+func (c *Context) GetCloudProfileConfig(ctx context.Context) (*vsphere.CloudProfileConfig, error) {
+	if err := c.load(ctx); err != nil {
+		return nil, err
+	}
+	return c.cloudProfileConfig, nil
+}
+
+func (c *Context) load(ctx context.Context) error {
+	if c.loaded {
+		return nil
+	}
+
+	ref := cloudProfileReference(c.shoot)
+	if ref.Kind == "NamespacedCloudProfile" {
+		namespacedCloudProfile := &core.NamespacedCloudProfile{}
+		if err := c.client.Get(ctx, kutil.Key(c.shoot.Namespace, ref.Name), namespacedCloudProfile); err != nil {
+			return fmt.Errorf("could not get NamespacedCloudProfile %q: %v", ref.Name, err)
+		}
+		c.namespacedCloudProfile = namespacedCloudProfile
+		ref = namespacedCloudProfile.Spec.Parent
+	}
+
+	cloudProfile := &gardencorev1beta1.CloudProfile{}
+	if err := c.client.Get(ctx, kutil.Key(ref.Name), cloudProfile); err != nil {
+		return err
+	}
+	if cloudProfile.Spec.ProviderConfig == nil {
+		return fmt.Errorf("providerConfig is not given for cloud profile %q", cloudProfile.Name)
+	}
+	parentConfig, err := helper.DecodeCloudProfileConfig(cloudProfile.Spec.ProviderConfig, providerConfigPath)
+	if err != nil {
+		return fmt.Errorf("an error occurred while reading the cloud profile %q: %v", cloudProfile.Name, err)
+	}
+
+	cloudProfileConfig := parentConfig
+	if c.namespacedCloudProfile != nil && c.namespacedCloudProfile.Spec.ProviderConfig != nil {
+		overrideConfig, err := helper.DecodeCloudProfileConfigFromCore(c.namespacedCloudProfile.Spec.ProviderConfig, providerConfigPath)
+		if err != nil {
+			return fmt.Errorf("an error occurred while reading the namespaced cloud profile %q: %v", c.namespacedCloudProfile.Name, err)
+		}
+		cloudProfileConfig, err = helper.MergeCloudProfileConfig(parentConfig, overrideConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.cloudProfile = cloudProfile
+	c.cloudProfileConfig = cloudProfileConfig
+	c.loaded = true
+	return nil
+}
+
+// cloudProfileReference returns the effective CloudProfile reference of the shoot, falling back to
+// the legacy spec.cloudProfileName if spec.cloudProfile (GEP-25) is not set. The shoot passed in is the
+// internal core.Shoot, so the reference returned is core.CloudProfileReference, not the external
+// gardencorev1beta1 type of the same shape.
+func cloudProfileReference(shoot *core.Shoot) core.CloudProfileReference {
+	if shoot.Spec.CloudProfile != nil {
+		return *shoot.Spec.CloudProfile
+	}
+	return core.CloudProfileReference{Kind: "CloudProfile", Name: shoot.Spec.CloudProfileName}
+}
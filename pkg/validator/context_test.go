@@ -0,0 +1,175 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gardencore "github.com/gardener/gardener/pkg/apis/core"
+	gardencoreinstall "github.com/gardener/gardener/pkg/apis/core/install"
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+
+	. "github.com/gardener/gardener-extension-provider-vsphere/pkg/validator"
+)
+
+func rawCloudProfileConfig(t *testing.T, regions ...string) []byte {
+	t.Helper()
+
+	type region struct {
+		Name string `json:"name"`
+	}
+	cfg := struct {
+		APIVersion string   `json:"apiVersion"`
+		Kind       string   `json:"kind"`
+		Regions    []region `json:"regions"`
+	}{
+		APIVersion: "vsphere.provider.extensions.gardener.cloud/v1alpha1",
+		Kind:       "CloudProfileConfig",
+	}
+	for _, r := range regions {
+		cfg.Regions = append(cfg.Regions, region{Name: r})
+	}
+
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("could not marshal fixture CloudProfileConfig: %v", err)
+	}
+	return raw
+}
+
+func newContextFakeClient(t *testing.T) client.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	gardencoreinstall.Install(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+func TestContext_GetCloudProfileConfig_LegacyCloudProfileName(t *testing.T) {
+	ctx := context.Background()
+	c := newContextFakeClient(t)
+
+	cloudProfile := &gardencorev1beta1.CloudProfile{
+		ObjectMeta: metav1.ObjectMeta{Name: "vsphere"},
+		Spec: gardencorev1beta1.CloudProfileSpec{
+			ProviderConfig: &gardencorev1beta1.ProviderConfig{RawExtension: runtime.RawExtension{Raw: rawCloudProfileConfig(t, "region1")}},
+		},
+	}
+	if err := c.Create(ctx, cloudProfile); err != nil {
+		t.Fatalf("could not create fixture CloudProfile: %v", err)
+	}
+
+	shoot := &gardencore.Shoot{
+		ObjectMeta: metav1.ObjectMeta{Name: "shoot1", Namespace: "garden-foo"},
+		Spec:       gardencore.ShootSpec{CloudProfileName: "vsphere"},
+	}
+
+	cpContext := NewContext(c, shoot)
+	cloudProfileConfig, err := cpContext.GetCloudProfileConfig(ctx)
+	if err != nil {
+		t.Fatalf("GetCloudProfileConfig failed: %v", err)
+	}
+	if len(cloudProfileConfig.Regions) != 1 || cloudProfileConfig.Regions[0].Name != "region1" {
+		t.Errorf("expected the parent's single region1 to be returned unchanged, got %+v", cloudProfileConfig.Regions)
+	}
+}
+
+func TestContext_GetCloudProfile_GEP25CloudProfileReference(t *testing.T) {
+	ctx := context.Background()
+	c := newContextFakeClient(t)
+
+	cloudProfile := &gardencorev1beta1.CloudProfile{
+		ObjectMeta: metav1.ObjectMeta{Name: "vsphere"},
+		Spec: gardencorev1beta1.CloudProfileSpec{
+			ProviderConfig: &gardencorev1beta1.ProviderConfig{RawExtension: runtime.RawExtension{Raw: rawCloudProfileConfig(t, "region1")}},
+		},
+	}
+	if err := c.Create(ctx, cloudProfile); err != nil {
+		t.Fatalf("could not create fixture CloudProfile: %v", err)
+	}
+
+	shoot := &gardencore.Shoot{
+		ObjectMeta: metav1.ObjectMeta{Name: "shoot1", Namespace: "garden-foo"},
+		Spec: gardencore.ShootSpec{
+			CloudProfile: &gardencore.CloudProfileReference{Kind: "CloudProfile", Name: "vsphere"},
+		},
+	}
+
+	cpContext := NewContext(c, shoot)
+	cloudProfile2, err := cpContext.GetCloudProfile(ctx)
+	if err != nil {
+		t.Fatalf("GetCloudProfile failed: %v", err)
+	}
+	if cloudProfile2.Name != "vsphere" {
+		t.Errorf("expected the directly-referenced CloudProfile %q, got %q", "vsphere", cloudProfile2.Name)
+	}
+}
+
+func TestContext_GetCloudProfileConfig_NamespacedCloudProfileMergesOverride(t *testing.T) {
+	ctx := context.Background()
+	c := newContextFakeClient(t)
+
+	parent := &gardencorev1beta1.CloudProfile{
+		ObjectMeta: metav1.ObjectMeta{Name: "vsphere"},
+		Spec: gardencorev1beta1.CloudProfileSpec{
+			ProviderConfig: &gardencorev1beta1.ProviderConfig{RawExtension: runtime.RawExtension{Raw: rawCloudProfileConfig(t, "region1")}},
+		},
+	}
+	if err := c.Create(ctx, parent); err != nil {
+		t.Fatalf("could not create fixture parent CloudProfile: %v", err)
+	}
+
+	namespaced := &gardencore.NamespacedCloudProfile{
+		ObjectMeta: metav1.ObjectMeta{Name: "project-override", Namespace: "garden-foo"},
+		Spec: gardencore.NamespacedCloudProfileSpec{
+			Parent:         gardencore.CloudProfileReference{Kind: "CloudProfile", Name: "vsphere"},
+			ProviderConfig: &gardencore.ProviderConfig{RawExtension: runtime.RawExtension{Raw: rawCloudProfileConfig(t, "region2")}},
+		},
+	}
+	if err := c.Create(ctx, namespaced); err != nil {
+		t.Fatalf("could not create fixture NamespacedCloudProfile: %v", err)
+	}
+
+	shoot := &gardencore.Shoot{
+		ObjectMeta: metav1.ObjectMeta{Name: "shoot1", Namespace: "garden-foo"},
+		Spec: gardencore.ShootSpec{
+			CloudProfile: &gardencore.CloudProfileReference{Kind: "NamespacedCloudProfile", Name: "project-override"},
+		},
+	}
+
+	cpContext := NewContext(c, shoot)
+	cloudProfileConfig, err := cpContext.GetCloudProfileConfig(ctx)
+	if err != nil {
+		t.Fatalf("GetCloudProfileConfig failed: %v", err)
+	}
+	if len(cloudProfileConfig.Regions) != 2 {
+		t.Fatalf("expected the namespaced region2 to be merged on top of the parent's region1, got %d regions", len(cloudProfileConfig.Regions))
+	}
+
+	cloudProfile, err := cpContext.GetCloudProfile(ctx)
+	if err != nil {
+		t.Fatalf("GetCloudProfile failed: %v", err)
+	}
+	if cloudProfile.Name != "vsphere" {
+		t.Errorf("expected GetCloudProfile to resolve to the NamespacedCloudProfile's parent %q, got %q", "vsphere", cloudProfile.Name)
+	}
+}
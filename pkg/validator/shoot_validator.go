@@ -16,14 +16,11 @@ package validator
 
 import (
 	"context"
-	"fmt"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/gardener/gardener/pkg/apis/core"
-	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
-	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
 
 	"github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere"
 	"github.com/gardener/gardener-extension-provider-vsphere/pkg/apis/vsphere/helper"
@@ -31,9 +28,10 @@ import (
 )
 
 type validationContext struct {
-	shoot       *core.Shoot
-	infraConfig *vsphere.InfrastructureConfig
-	cpConfig    *vsphere.ControlPlaneConfig
+	shoot         *core.Shoot
+	infraConfig   *vsphere.InfrastructureConfig
+	cpConfig      *vsphere.ControlPlaneConfig
+	cloudProfiles *Context
 }
 
 var (
@@ -47,15 +45,15 @@ var (
 )
 
 func (v *Shoot) validateShootCreation(ctx context.Context, shoot *core.Shoot) error {
-	valContext, err := newValidationContext(v.decoder, shoot)
+	valContext, err := v.newValidationContext(v.decoder, shoot)
 	if err != nil {
 		return err
 	}
 
-	if err := v.validateInfraAgainstCloudProfile(ctx, shoot, valContext.infraConfig, infraConfigPath); err != nil {
+	if err := v.validateInfraAgainstCloudProfile(ctx, valContext.cloudProfiles, shoot, valContext.infraConfig, infraConfigPath); err != nil {
 		return err
 	}
-	if err := v.validateCpAgainstCloudProfile(ctx, shoot, valContext.cpConfig, cpConfigPath); err != nil {
+	if err := v.validateCpAgainstCloudProfile(ctx, valContext.cloudProfiles, shoot, valContext.cpConfig, cpConfigPath); err != nil {
 		return err
 	}
 
@@ -63,12 +61,12 @@ func (v *Shoot) validateShootCreation(ctx context.Context, shoot *core.Shoot) er
 }
 
 func (v *Shoot) validateShootUpdate(ctx context.Context, oldShoot, shoot *core.Shoot) error {
-	oldValContext, err := newValidationContext(v.decoder, oldShoot)
+	oldValContext, err := v.newValidationContext(v.decoder, oldShoot)
 	if err != nil {
 		return err
 	}
 
-	valContext, err := newValidationContext(v.decoder, shoot)
+	valContext, err := v.newValidationContext(v.decoder, shoot)
 	if err != nil {
 		return err
 	}
@@ -108,18 +106,10 @@ func (v *Shoot) validateShoot(ctx context.Context, context *validationContext) e
 	return nil
 }
 
-func (v *Shoot) validateInfraAgainstCloudProfile(ctx context.Context, shoot *core.Shoot, infraConfig *vsphere.InfrastructureConfig, fldPath *field.Path) error {
-	cloudProfile := &gardencorev1beta1.CloudProfile{}
-	if err := v.client.Get(ctx, kutil.Key(shoot.Spec.CloudProfileName), cloudProfile); err != nil {
-		return err
-	}
-
-	if cloudProfile.Spec.ProviderConfig == nil {
-		return fmt.Errorf("providerConfig is not given for cloud profile %q", cloudProfile.Name)
-	}
-	cloudProfileConfig, err := helper.DecodeCloudProfileConfig(cloudProfile.Spec.ProviderConfig, providerConfigPath)
+func (v *Shoot) validateInfraAgainstCloudProfile(ctx context.Context, cpContext *Context, shoot *core.Shoot, infraConfig *vsphere.InfrastructureConfig, fldPath *field.Path) error {
+	cloudProfileConfig, err := cpContext.GetCloudProfileConfig(ctx)
 	if err != nil {
-		return fmt.Errorf("an error occurred while reading the cloud profile %q: %v", cloudProfile.Name, err)
+		return err
 	}
 
 	if errList := vspherevalidation.ValidateInfrastructureConfigAgainstCloudProfile(infraConfig, shoot.Spec.Region, cloudProfileConfig, fldPath); len(errList) != 0 {
@@ -129,28 +119,20 @@ func (v *Shoot) validateInfraAgainstCloudProfile(ctx context.Context, shoot *cor
 	return nil
 }
 
-func (v *Shoot) validateCpAgainstCloudProfile(ctx context.Context, shoot *core.Shoot, cpConfig *vsphere.ControlPlaneConfig, fldPath *field.Path) error {
-	cloudProfile := &gardencorev1beta1.CloudProfile{}
-	if err := v.client.Get(ctx, kutil.Key(shoot.Spec.CloudProfileName), cloudProfile); err != nil {
-		return err
-	}
-
-	if cloudProfile.Spec.ProviderConfig == nil {
-		return fmt.Errorf("providerConfig is not given for cloud profile %q", cloudProfile.Name)
-	}
-	cloudProfileConfig, err := helper.DecodeCloudProfileConfig(cloudProfile.Spec.ProviderConfig, providerConfigPath)
+func (v *Shoot) validateCpAgainstCloudProfile(ctx context.Context, cpContext *Context, shoot *core.Shoot, cpConfig *vsphere.ControlPlaneConfig, fldPath *field.Path) error {
+	cloudProfileConfig, err := cpContext.GetCloudProfileConfig(ctx)
 	if err != nil {
-		return fmt.Errorf("an error occurred while reading the cloud profile %q: %v", cloudProfile.Name, err)
+		return err
 	}
 
-	if errList := vspherevalidation.ValidateControlPlaneConfigAgainstCloudProfile(cpConfig, shoot.Spec.Region, cloudProfile, cloudProfileConfig, fldPath); len(errList) != 0 {
+	if errList := vspherevalidation.ValidateControlPlaneConfigAgainstCloudProfile(cpConfig, shoot.Spec.Region, cloudProfileConfig, fldPath); len(errList) != 0 {
 		return errList.ToAggregate()
 	}
 
 	return nil
 }
 
-func newValidationContext(decoder runtime.Decoder, shoot *core.Shoot) (*validationContext, error) {
+func (v *Shoot) newValidationContext(decoder runtime.Decoder, shoot *core.Shoot) (*validationContext, error) {
 	if shoot.Spec.Provider.InfrastructureConfig == nil {
 		return nil, field.Required(infraConfigPath, "infrastructureConfig must be set for OpenStack shoots")
 	}
@@ -168,8 +150,9 @@ func newValidationContext(decoder runtime.Decoder, shoot *core.Shoot) (*validati
 	}
 
 	return &validationContext{
-		shoot:       shoot,
-		infraConfig: infraConfig,
-		cpConfig:    cpConfig,
+		shoot:         shoot,
+		infraConfig:   infraConfig,
+		cpConfig:      cpConfig,
+		cloudProfiles: NewContext(v.client, shoot),
 	}, nil
 }